@@ -0,0 +1,149 @@
+// Command example-provider 是 internal/provider/plugin 契约的参考实现：
+// 一个独立进程，监听一个 unix socket，对 Generate 请求返回一张纯色 PNG。
+// 它的作用只是把 InitProviders 的插件发现路径端到端跑通，接入真正的
+// 模型（Midjourney、Stable Diffusion、自研模型等）时可以照这个骨架改。
+//
+// 用法：
+//
+//	go run ./cmd/example-provider -socket ./providers.d/example.sock
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"net"
+	"os"
+
+	"google.golang.org/grpc"
+
+	_ "image-gen-service/internal/provider/plugin" // 注册 json codec
+)
+
+const serviceName = "/provider.ProviderPlugin"
+
+type describeRequest struct{}
+type describeResponse struct {
+	Name         string   `json:"name"`
+	Capabilities []string `json:"capabilities"`
+	Purposes     []string `json:"purposes"`
+}
+
+type validateParamsRequest struct {
+	Params map[string]interface{} `json:"params"`
+}
+type validateParamsResponse struct {
+	Valid  bool   `json:"valid"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type generateRequest struct {
+	Params map[string]interface{} `json:"params"`
+}
+type generateChunk struct {
+	ImageChunk []byte                 `json:"imageChunk,omitempty"`
+	Done       bool                   `json:"done"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+func main() {
+	socketPath := flag.String("socket", "./providers.d/example-provider.sock", "unix socket 监听地址")
+	flag.Parse()
+
+	if err := os.MkdirAll(parentDir(*socketPath), 0o755); err != nil {
+		log.Fatalf("[example-provider] 创建 socket 目录失败: %v", err)
+	}
+	os.Remove(*socketPath)
+
+	lis, err := net.Listen("unix", *socketPath)
+	if err != nil {
+		log.Fatalf("[example-provider] 监听 %s 失败: %v", *socketPath, err)
+	}
+
+	server := grpc.NewServer()
+	server.RegisterService(&serviceDesc, nil)
+
+	log.Printf("[example-provider] 监听中: %s", *socketPath)
+	if err := server.Serve(lis); err != nil {
+		log.Fatalf("[example-provider] 服务退出: %v", err)
+	}
+}
+
+func parentDir(path string) string {
+	dir := "."
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '/' {
+			dir = path[:i]
+			break
+		}
+	}
+	return dir
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "provider.ProviderPlugin",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Describe", Handler: describeHandler},
+		{MethodName: "ValidateParams", Handler: validateParamsHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Generate", Handler: generateHandler, ServerStreams: true},
+	},
+}
+
+func describeHandler(_ interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &describeRequest{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	return &describeResponse{
+		Name:         "example-provider",
+		Capabilities: []string{"ratio:1:1", "ratio:16:9", "ref-image"},
+		Purposes:     []string{"image"},
+	}, nil
+}
+
+func validateParamsHandler(_ interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &validateParamsRequest{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	return &validateParamsResponse{Valid: true}, nil
+}
+
+func generateHandler(_ interface{}, stream grpc.ServerStream) error {
+	req := &generateRequest{}
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+
+	imgBytes, err := solidColorPNG(512, 512, color.RGBA{R: 0x4a, G: 0x7c, B: 0xff, A: 0xff})
+	if err != nil {
+		return stream.SendMsg(&generateChunk{Done: true, Error: err.Error()})
+	}
+
+	if err := stream.SendMsg(&generateChunk{ImageChunk: imgBytes}); err != nil {
+		return err
+	}
+	return stream.SendMsg(&generateChunk{Done: true, Metadata: map[string]interface{}{"source": "example-provider"}})
+}
+
+func solidColorPNG(w, h int, c color.RGBA) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
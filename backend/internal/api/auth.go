@@ -0,0 +1,192 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"image-gen-service/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+// Scope 是 JWT 里携带的权限范围
+const (
+	ScopeAnonymous = "anonymous" // 只能访问公开接口，如模板市场
+	ScopeUser      = "user"
+	ScopeAdmin     = "admin" // 租户管理员，可以查看/订阅租户下所有用户的任务
+)
+
+const (
+	ctxKeyUserID   = "auth.userId"
+	ctxKeyScope    = "auth.scope"
+	ctxKeyTenantID = "auth.tenantId"
+)
+
+// Claims 是 JWT payload 里携带的业务字段
+type Claims struct {
+	Scope    string `json:"scope"`
+	TenantID string `json:"tenantId"`
+	jwt.RegisteredClaims
+}
+
+// jwtSecret 由 SetJWTSecret 在启动时注入，避免把密钥硬编码在这里
+var jwtSecret []byte
+
+// SetJWTSecret 配置用于校验 JWT 的密钥（在 main.go 里从配置/环境变量读取后调用）
+func SetJWTSecret(secret string) {
+	jwtSecret = []byte(secret)
+}
+
+// RequireAuth 校验 JWT（通过 Authorization: Bearer 头，或 WebSocket 升级场景下的
+// ?token= 查询参数），并把 sub/scope/tenantId 写入 gin.Context 供后续 handler 使用。
+// allowAnonymous 为 true 时，缺失或校验失败的 token 会被当作 ScopeAnonymous 放行，
+// 用于 ListTemplatesHandler 这类需要公开浏览的接口。
+func RequireAuth(allowAnonymous bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := extractToken(c)
+		if token == "" {
+			if allowAnonymous {
+				c.Set(ctxKeyScope, ScopeAnonymous)
+				c.Next()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
+			return
+		}
+
+		claims := &Claims{}
+		parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+			return jwtSecret, nil
+		})
+		if err != nil || !parsed.Valid {
+			if allowAnonymous {
+				c.Set(ctxKeyScope, ScopeAnonymous)
+				c.Next()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+
+		c.Set(ctxKeyUserID, claims.Subject)
+		c.Set(ctxKeyScope, claims.Scope)
+		c.Set(ctxKeyTenantID, claims.TenantID)
+		c.Next()
+	}
+}
+
+func extractToken(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	return c.Query("token")
+}
+
+// currentUser 读取 RequireAuth 写入的身份信息
+func currentUser(c *gin.Context) (userID, scope, tenantID string) {
+	return c.GetString(ctxKeyUserID), c.GetString(ctxKeyScope), c.GetString(ctxKeyTenantID)
+}
+
+// canAccessTask 判断当前请求方是否有权访问某个任务：任务所有者本人，
+// 或者同租户下的管理员
+func canAccessTask(c *gin.Context, task *model.Task) bool {
+	userID, scope, tenantID := currentUser(c)
+	if userID != "" && userID == task.UserID {
+		return true
+	}
+	if scope == ScopeAdmin && tenantID != "" && tenantID == task.TenantID {
+		return true
+	}
+	return false
+}
+
+// --- 限流 + 配额 ---
+
+// createLimiters 按 userID 缓存每个用户的任务创建限流器
+var (
+	createLimiters   = make(map[string]*rate.Limiter)
+	createLimitersMu sync.Mutex
+)
+
+// createRateLimit 是允许的任务创建速率：平均每分钟 10 个，允许突发 5 个
+const (
+	createRateLimit = rate.Limit(10.0 / 60.0)
+	createBurst     = 5
+)
+
+func limiterFor(userID string) *rate.Limiter {
+	createLimitersMu.Lock()
+	defer createLimitersMu.Unlock()
+	l, ok := createLimiters[userID]
+	if !ok {
+		l = rate.NewLimiter(createRateLimit, createBurst)
+		createLimiters[userID] = l
+	}
+	return l
+}
+
+// EnforceQuota 是挂在任务创建路由上的中间件：做每用户创建速率限制、
+// 每用户在所属租户下的月度配额检查（原子自增，避免并发请求绕过上限）。
+// 计数按 (user_id, tenant_id, month) 分行，租户内所有用户的用量不做
+// 汇总，也没有租户级的总上限。这条路由还没有
+// task_id（CreateTaskHandler 稍后才会生成），所以生成审计日志由
+// CreateTaskHandler 在拿到 taskID 之后自己写，不在这里写。
+// 要求 RequireAuth 已经运行过。
+func EnforceQuota() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, scope, tenantID := currentUser(c)
+		if scope == ScopeAnonymous || userID == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+
+		if !limiterFor(userID).Allow() {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+
+		month := time.Now().Format("2006-01")
+		ensureQuotaRow(userID, tenantID, month)
+
+		// 用一条原子的条件 UPDATE 代替"先 First 读出 ImageCount，再算出
+		// +1 写回"：两个并发请求会读到同一个 ImageCount，各自判断没超限，
+		// 再各自写回同一个递增后的值——配额检查形同虚设，用量还被算少了
+		// 一次。这里把"判断是否超限"和"自增"收进同一条 SQL，
+		// RowsAffected == 0 就说明已经到达配额上限。
+		result := model.DB.Model(&model.UserQuota{}).
+			Where("user_id = ? AND tenant_id = ? AND month = ? AND (image_limit <= 0 OR image_count < image_limit)",
+				userID, tenantID, month).
+			Update("image_count", gorm.Expr("image_count + 1"))
+		if result.Error != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "配额检查失败"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.AbortWithStatusJSON(http.StatusPaymentRequired, gin.H{"error": "monthly image quota exceeded"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ensureQuotaRow 保证 (userID, tenantID, month) 对应的配额行存在，供后面的
+// 原子自增 UPDATE 命中。并发场景下可能有多个请求同时发现行不存在、都尝试
+// Create：UserQuota 在 (user_id, tenant_id, month) 上有唯一索引，多余的
+// Create 会因为违反唯一约束失败，这里忽略该错误即可——后面的 UPDATE 只要
+// 这行存在就能生效。
+func ensureQuotaRow(userID, tenantID, month string) {
+	var quota model.UserQuota
+	if err := model.DB.Where("user_id = ? AND tenant_id = ? AND month = ?", userID, tenantID, month).
+		First(&quota).Error; err != nil {
+		model.DB.Create(&model.UserQuota{UserID: userID, TenantID: tenantID, Month: month, ImageLimit: defaultMonthlyImageLimit})
+	}
+}
+
+// defaultMonthlyImageLimit 是没有显式配置配额时的默认月度上限
+const defaultMonthlyImageLimit = 1000
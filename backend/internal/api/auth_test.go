@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"image-gen-service/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testContext(t *testing.T) *gin.Context {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	return c
+}
+
+func TestCanAccessTaskOwner(t *testing.T) {
+	c := testContext(t)
+	c.Set(ctxKeyUserID, "u1")
+
+	task := &model.Task{UserID: "u1"}
+	if !canAccessTask(c, task) {
+		t.Fatal("任务所有者本人应该可以访问")
+	}
+}
+
+func TestCanAccessTaskDeniedForOtherUser(t *testing.T) {
+	c := testContext(t)
+	c.Set(ctxKeyUserID, "u2")
+
+	task := &model.Task{UserID: "u1"}
+	if canAccessTask(c, task) {
+		t.Fatal("非所有者不应该可以访问")
+	}
+}
+
+func TestCanAccessTaskDeniedForAnonymous(t *testing.T) {
+	c := testContext(t)
+	c.Set(ctxKeyScope, ScopeAnonymous)
+
+	task := &model.Task{UserID: "u1"}
+	if canAccessTask(c, task) {
+		t.Fatal("未认证/匿名请求不应该可以访问任何人的任务")
+	}
+}
+
+func TestCanAccessTaskAdminSameTenant(t *testing.T) {
+	c := testContext(t)
+	c.Set(ctxKeyScope, ScopeAdmin)
+	c.Set(ctxKeyTenantID, "tenant-1")
+
+	task := &model.Task{UserID: "someone-else", TenantID: "tenant-1"}
+	if !canAccessTask(c, task) {
+		t.Fatal("同租户的管理员应该可以访问")
+	}
+}
+
+func TestCanAccessTaskAdminDifferentTenant(t *testing.T) {
+	c := testContext(t)
+	c.Set(ctxKeyScope, ScopeAdmin)
+	c.Set(ctxKeyTenantID, "tenant-1")
+
+	task := &model.Task{UserID: "someone-else", TenantID: "tenant-2"}
+	if canAccessTask(c, task) {
+		t.Fatal("不同租户的管理员不应该可以访问")
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+
+	"image-gen-service/internal/downloader"
+	"image-gen-service/internal/model"
+	"image-gen-service/internal/provider"
+	"image-gen-service/internal/worker"
+
+	"github.com/gin-gonic/gin"
+)
+
+// createTaskRequest 是发起一次生成任务的请求体
+type createTaskRequest struct {
+	ProviderName string                 `json:"providerName" binding:"required"`
+	Purpose      string                 `json:"purpose"`
+	ModelID      string                 `json:"modelId"`
+	Params       map[string]interface{} `json:"params"`
+	Ratio        string                 `json:"ratio"`
+	Material     string                 `json:"material"`
+	Industry     string                 `json:"industry"`
+}
+
+// CreateTaskHandler 创建一条生成任务记录，并异步驱动 worker.RunGenerate：
+// RunGenerate 内部先把参考图投递给 downloader 异步转存（不等待完成），
+// 再做 RouteModel 选型与 fallback 重试，
+// 进度/结果通过 worker.Notifier 回传给 GenerateWSHandler/TaskStreamHandler
+// 的订阅者，这里只负责建任务和把请求参数转交下去，不等待生成结束。
+func CreateTaskHandler(c *gin.Context) {
+	var req createTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	taskID, err := newTaskID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "task id 生成失败"})
+		return
+	}
+
+	userID, _, tenantID := currentUser(c)
+	task := model.Task{
+		TaskID:     taskID,
+		UserID:     userID,
+		TenantID:   tenantID,
+		Status:     "pending",
+		TotalCount: 1,
+	}
+	if err := model.DB.Create(&task).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建任务失败"})
+		return
+	}
+
+	// EnforceQuota 运行的时候这个任务还不存在，c.Param("task_id") 在这条
+	// POST 路由上永远是空字符串，所以生成审计日志放在这里、taskID 已经
+	// 确定之后写，而不是在 EnforceQuota 里写。
+	writeGenerateAuditLog(c, taskID)
+
+	params := worker.GenerateParams{
+		ProviderName: req.ProviderName,
+		Purpose:      provider.ModelPurpose(req.Purpose),
+		RequestModel: req.ModelID,
+		Params:       req.Params,
+		Route: provider.RouteRequest{
+			Ratio:    req.Ratio,
+			Material: req.Material,
+			Industry: req.Industry,
+		},
+	}
+
+	// downloader.Start 只在这里被真正调用到：worker.Downloads 只有 Start
+	// 跑过一次之后才会非 nil，而 worker 包自己不能调用 downloader.Start
+	// （会成环），所以这一步不能省略，否则 enqueueReferenceImages 里的
+	// worker.Downloads == nil 检查会让参考图下载永远不会触发。
+	downloader.Start()
+
+	// 生成是异步的：客户端先拿到 taskId，再通过 /ws 或 /stream 订阅进度，
+	// 这里不阻塞当前请求等待 RunGenerate 跑完。
+	go func() {
+		if err := worker.RunGenerate(context.Background(), &task, params); err != nil {
+			log.Printf("[API] 任务 %s 生成失败: %v", taskID, err)
+		}
+	}()
+
+	Success(c, gin.H{"taskId": taskID})
+}
+
+// newTaskID 生成一个随机的任务 ID，和 downloader.newGID 的做法保持一致
+func newTaskID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// writeGenerateAuditLog 记录一次生成请求，供合规/排障使用，和
+// websocket.go 里的 writeSubscribeAuditLog 是同一种审计日志、不同的
+// Action。必须在 taskID 确定之后调用。
+func writeGenerateAuditLog(c *gin.Context, taskID string) {
+	userID, _, tenantID := currentUser(c)
+	model.DB.Create(&model.AuditLog{
+		UserID:   userID,
+		TenantID: tenantID,
+		TaskID:   taskID,
+		Action:   "generate",
+	})
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes 把这个包里的 HTTP/WebSocket 路由挂到 Gin 引擎上，
+// 由 cmd/server/main.go 在启动时调用。
+func RegisterRoutes(r *gin.Engine) {
+	api := r.Group("/api")
+
+	api.GET("/templates", RequireAuth(true), ListTemplatesHandler)
+
+	// /tasks 下所有接口都要求调用方带着有效 JWT：创建任务、订阅 WS/SSE
+	// 都需要知道 userID/scope/tenantId 才能做 EnforceQuota 和 canAccessTask
+	// 判断，所以这里不允许匿名放行。
+	tasks := api.Group("/tasks")
+	tasks.Use(RequireAuth(false))
+	tasks.POST("", EnforceQuota(), CreateTaskHandler)
+	tasks.GET("/:task_id/ws", GenerateWSHandler)
+	tasks.GET("/:task_id/stream", TaskStreamHandler)
+}
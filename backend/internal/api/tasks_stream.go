@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"image-gen-service/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamHeartbeatInterval 是 SSE 心跳注释的发送间隔，避免中间代理因为
+// 长时间没有数据而把连接当成空闲关闭
+const streamHeartbeatInterval = 15 * time.Second
+
+// TaskStreamHandler 用 HTTP chunked transfer（text/event-stream）把 Hub 里
+// 同一份事件流再开放给没法长期持有 WebSocket 的客户端（移动端 Safari 在某些
+// 代理后面、curl 脚本、CI）。和 GenerateWSHandler 共用 taskHub.Register，
+// 只是订阅 key 换成了这条 HTTP 连接自己的 token。
+//
+// 客户端可以带上 Last-Event-ID 请求头（或 ?last_event_id= 查询参数）从断线
+// 前的位置续传：服务端先把 model.TaskEvent 里更大序号的历史事件回放一遍，
+// 再切换到实时推送。
+func TaskStreamHandler(c *gin.Context) {
+	taskID := c.Param("task_id")
+	if taskID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "task_id is required"})
+		return
+	}
+
+	var task model.Task
+	if err := model.DB.Where("task_id = ?", taskID).First(&task).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return
+	}
+
+	if !canAccessTask(c, &task) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "streaming unsupported"})
+		return
+	}
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.WriteHeader(http.StatusOK)
+
+	// 订阅 key 只需要在这条连接的生命周期内唯一，用这条连接自己的指针即可。
+	// 必须先订阅再回放历史：如果反过来，回放查询和订阅建立之间的空隙里
+	// 发布的事件既不在回放结果里，也还没进订阅 channel，会被永久漏掉。
+	key := c.Request
+	messages := taskSubscribers.Register(taskID, key)
+	defer taskSubscribers.Unregister(taskID, key)
+
+	lastSeq := lastEventID(c)
+	replayed := replayEvents(taskID, lastSeq)
+	for _, evt := range replayed {
+		writeSSEEvent(c.Writer, evt)
+		lastSeq = evt.Seq
+	}
+	flusher.Flush()
+
+	// 任务在订阅建立前就已经是终态：回放里已经包含了 complete/error 事件，
+	// 没有更多事件会到来，直接结束这条连接，不需要再进入实时循环。
+	if len(replayed) > 0 {
+		if last := replayed[len(replayed)-1]; last.Type == "complete" || last.Type == "error" {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			flusher.Flush()
+
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			if isStaleReplayedEvent(msg.Seq, lastSeq) {
+				// 订阅建立到回放查询之间发布的事件，回放已经读到过了，跳过去重
+				continue
+			}
+			writeSSEEvent(c.Writer, msg)
+			flusher.Flush()
+			lastSeq = msg.Seq
+			if msg.Type == "complete" || msg.Type == "error" {
+				return
+			}
+		}
+	}
+}
+
+// lastEventID 读取浏览器原生 EventSource 重连时带上的 Last-Event-ID 头，
+// 也兼容手写客户端用查询参数传递的同等信息
+func lastEventID(c *gin.Context) int64 {
+	raw := c.GetHeader("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("last_event_id")
+	}
+	seq, _ := strconv.ParseInt(raw, 10, 64)
+	return seq
+}
+
+// replayEvents 从持久化的事件日志里取出断线期间错过的事件
+func replayEvents(taskID string, afterSeq int64) []WSProgressMessage {
+	var rows []model.TaskEvent
+	if err := model.DB.Where("task_id = ? AND seq > ?", taskID, afterSeq).
+		Order("seq asc").Find(&rows).Error; err != nil {
+		return nil
+	}
+
+	events := make([]WSProgressMessage, 0, len(rows))
+	for _, row := range rows {
+		var msg WSProgressMessage
+		if err := json.Unmarshal([]byte(row.Payload), &msg); err != nil {
+			continue
+		}
+		events = append(events, msg)
+	}
+	return events
+}
+
+// isStaleReplayedEvent 判断一个从 Hub 实时 channel 收到的事件，是否已经在
+// Last-Event-ID 回放阶段读到过——回放和实时订阅之间没有硬性的时间边界，
+// 两边读到同一个 seq 是预期内的重叠，这里按 seq 去重。
+func isStaleReplayedEvent(seq, lastSeq int64) bool {
+	return seq <= lastSeq
+}
+
+func writeSSEEvent(w http.ResponseWriter, msg WSProgressMessage) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", msg.Seq, msg.Type, payload)
+}
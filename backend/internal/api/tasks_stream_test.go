@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestIsStaleReplayedEvent 覆盖 TaskStreamHandler 从回放切到实时推送时的
+// 去重判断：回放阶段已经读到过的 seq（以及回放阶段本身产生的 seq）都不应该
+// 在实时循环里重复写给客户端。
+func TestIsStaleReplayedEvent(t *testing.T) {
+	cases := []struct {
+		name    string
+		seq     int64
+		lastSeq int64
+		want    bool
+	}{
+		{"早于回放位点", 3, 5, true},
+		{"等于回放位点", 5, 5, true},
+		{"晚于回放位点", 6, 5, false},
+		{"没有回放过任何事件", 1, 0, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isStaleReplayedEvent(tc.seq, tc.lastSeq); got != tc.want {
+				t.Errorf("isStaleReplayedEvent(%d, %d) = %v, want %v", tc.seq, tc.lastSeq, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestWriteSSEEventRoundTrips 验证 writeSSEEvent 写出的 `id`/`data` 字段
+// 能还原出与写入前一致的 seq 和消息体，这是 Last-Event-ID 续传能正确工作
+// 的前提。
+func TestWriteSSEEventRoundTrips(t *testing.T) {
+	w := httptest.NewRecorder()
+	msg := WSProgressMessage{Seq: 42, Type: "progress", CompletedCount: 1, TotalCount: 2}
+
+	writeSSEEvent(w, msg)
+
+	body := w.Body.String()
+	if !strings.Contains(body, "id: 42\n") {
+		t.Fatalf("SSE 输出里缺少 id: 42，got %q", body)
+	}
+	if !strings.Contains(body, "event: progress\n") {
+		t.Fatalf("SSE 输出里缺少 event: progress，got %q", body)
+	}
+
+	dataLine := ""
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "data: ") {
+			dataLine = strings.TrimPrefix(line, "data: ")
+			break
+		}
+	}
+	if dataLine == "" {
+		t.Fatalf("SSE 输出里没有 data 行，got %q", body)
+	}
+
+	var got WSProgressMessage
+	if err := json.Unmarshal([]byte(dataLine), &got); err != nil {
+		t.Fatalf("反序列化 data 行失败: %v", err)
+	}
+	if got.Seq != msg.Seq || got.Type != msg.Type || got.CompletedCount != msg.CompletedCount {
+		t.Fatalf("writeSSEEvent 往返后消息不一致: got %+v, want %+v", got, msg)
+	}
+}
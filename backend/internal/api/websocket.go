@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
 	"sync"
@@ -28,10 +29,15 @@ func (n *wsNotifier) NotifyProgress(taskID string, completedCount, totalCount in
 	NotifyTaskProgress(taskID, completedCount, totalCount, image)
 }
 
+func (n *wsNotifier) NotifyModelSelected(taskID, modelID, source string) {
+	NotifyTaskModelSelected(taskID, modelID, source)
+}
+
 // InitWSNotifier 初始化 WebSocket 通知器（在 main.go 中调用）
 func InitWSNotifier() {
 	worker.Notifier = &wsNotifier{}
-	log.Println("[WebSocket] 通知器已注册")
+	go taskHub.run()
+	log.Println("[WebSocket] 通知器已注册，事件 Hub 已启动")
 }
 
 var upgrader = websocket.Upgrader{
@@ -42,61 +48,141 @@ var upgrader = websocket.Upgrader{
 	WriteBufferSize: 1024,
 }
 
-// TaskSubscriber 管理任务的 WebSocket 订阅者
-type TaskSubscriber struct {
-	mu          sync.RWMutex
-	subscribers map[string]map[*websocket.Conn]bool // taskID -> connections
+// WSProgressMessage WebSocket 进度消息，也是 SSE 传输的事件负载
+type WSProgressMessage struct {
+	Seq            int64       `json:"seq,omitempty"`         // 任务内单调递增的事件序号，用于断线续传
+	Type           string      `json:"type"`                  // "progress", "complete", "error", "model"
+	CompletedCount int         `json:"completedCount"`        // 已完成数量
+	TotalCount     int         `json:"totalCount"`            // 总数量
+	LatestImage    interface{} `json:"latestImage"`           // 最新生成的图片信息
+	Message        string      `json:"message"`               // 错误消息（仅 error 类型）
+	ModelID        string      `json:"modelId,omitempty"`     // 路由/加权选择最终选中的模型（仅 model 类型）
+	ModelSource    string      `json:"modelSource,omitempty"` // 选中依据："request"/"params"/"config"/"default"/"route"
 }
 
-var taskSubscribers = &TaskSubscriber{
-	subscribers: make(map[string]map[*websocket.Conn]bool),
+// subscriberSendBuffer 是每个订阅者发送队列的容量。
+// 队列满时 Hub 会丢弃本次事件而不是阻塞，避免一个慢客户端拖慢其他连接的广播。
+const subscriberSendBuffer = 16
+
+// reconcileInterval 是兜底轮询的间隔。正常情况下事件完全由 Hub 推送，
+// 这个轮询只用来兜底 Hub 丢事件或进程重启等极端场景。
+const reconcileInterval = 20 * time.Second
+
+// hubEvent 是推送给 Hub 的一次任务事件
+type hubEvent struct {
+	taskID  string
+	message WSProgressMessage
 }
 
-// Subscribe 订阅任务更新
-func (ts *TaskSubscriber) Subscribe(taskID string, conn *websocket.Conn) {
-	ts.mu.Lock()
-	defer ts.mu.Unlock()
-	if ts.subscribers[taskID] == nil {
-		ts.subscribers[taskID] = make(map[*websocket.Conn]bool)
+// subscriberKey 标识一条订阅的归属连接。WebSocket 用 *websocket.Conn，
+// SSE/chunked 流式接口（见 tasks_stream.go）用它们自己分配的 token，
+// 这样同一个 Hub 能同时给两种传输方式扇出事件。
+type subscriberKey interface{}
+
+// subscription 代表一条任务订阅，拥有独立的发送队列用于背压
+type subscription struct {
+	taskID string
+	key    subscriberKey
+	send   chan WSProgressMessage
+}
+
+// Hub 是进程内唯一的事件分发中心：worker 只管把事件丢进 events，
+// 所有连接的订阅关系、扇出、慢客户端背压都由 Hub 的单一 goroutine 负责，
+// 不再像过去那样在持有锁的情况下对每个连接做网络 IO。
+type Hub struct {
+	subscribers map[string]map[subscriberKey]*subscription // taskID -> key -> subscription
+	register    chan *subscription
+	unregister  chan *subscription
+	events      chan hubEvent
+}
+
+var taskHub = newHub()
+
+func newHub() *Hub {
+	return &Hub{
+		subscribers: make(map[string]map[subscriberKey]*subscription),
+		register:    make(chan *subscription),
+		unregister:  make(chan *subscription),
+		events:      make(chan hubEvent, 256),
 	}
-	ts.subscribers[taskID][conn] = true
 }
 
-// Unsubscribe 取消订阅
-func (ts *TaskSubscriber) Unsubscribe(taskID string, conn *websocket.Conn) {
-	ts.mu.Lock()
-	defer ts.mu.Unlock()
-	if conns, ok := ts.subscribers[taskID]; ok {
-		delete(conns, conn)
-		if len(conns) == 0 {
-			delete(ts.subscribers, taskID)
+// run 是 Hub 的主循环，进程内只应启动一个，独占 subscribers 的读写
+func (h *Hub) run() {
+	for {
+		select {
+		case sub := <-h.register:
+			if h.subscribers[sub.taskID] == nil {
+				h.subscribers[sub.taskID] = make(map[subscriberKey]*subscription)
+			}
+			h.subscribers[sub.taskID][sub.key] = sub
+
+		case sub := <-h.unregister:
+			if subs, ok := h.subscribers[sub.taskID]; ok {
+				if existing, ok := subs[sub.key]; ok {
+					delete(subs, sub.key)
+					close(existing.send)
+					if len(subs) == 0 {
+						delete(h.subscribers, sub.taskID)
+					}
+				}
+			}
+
+		case evt := <-h.events:
+			for _, sub := range h.subscribers[evt.taskID] {
+				select {
+				case sub.send <- evt.message:
+				default:
+					log.Printf("[WebSocket] 订阅者发送队列已满，丢弃事件，任务ID: %s", evt.taskID)
+				}
+			}
 		}
 	}
 }
 
-// Broadcast 向任务的所有订阅者广播消息
-func (ts *TaskSubscriber) Broadcast(taskID string, message interface{}) {
-	ts.mu.RLock()
-	conns := ts.subscribers[taskID]
-	ts.mu.RUnlock()
-
-	for conn := range conns {
-		err := conn.WriteJSON(message)
-		if err != nil {
-			log.Printf("[WebSocket] 发送消息失败: %v", err)
-			conn.Close()
-			ts.Unsubscribe(taskID, conn)
-		}
+// Register 注册一条订阅，返回一个带缓冲的 channel，由 Hub 统一扇出事件。
+// key 只需要在同一个 taskID 下唯一即可，调用方在连接结束时必须调用
+// Unregister 释放资源。
+func (h *Hub) Register(taskID string, key subscriberKey) <-chan WSProgressMessage {
+	sub := &subscription{
+		taskID: taskID,
+		key:    key,
+		send:   make(chan WSProgressMessage, subscriberSendBuffer),
 	}
+	h.register <- sub
+	return sub.send
 }
 
-// WSProgressMessage WebSocket 进度消息
-type WSProgressMessage struct {
-	Type           string      `json:"type"`           // "progress", "complete", "error"
-	CompletedCount int         `json:"completedCount"` // 已完成数量
-	TotalCount     int         `json:"totalCount"`     // 总数量
-	LatestImage    interface{} `json:"latestImage"`    // 最新生成的图片信息
-	Message        string      `json:"message"`        // 错误消息（仅 error 类型）
+// Unregister 注销订阅，关闭对应的发送 channel
+func (h *Hub) Unregister(taskID string, key subscriberKey) {
+	h.unregister <- &subscription{taskID: taskID, key: key}
+}
+
+// Publish 推送一个任务事件，由 worker 在状态变化时调用，不做任何网络 IO。
+// 事件在扇出之前会先落盘（见 persistTaskEvent），这样短暂离线的流式
+// 客户端可以凭 Last-Event-ID 从持久化的事件日志里补齐错过的事件。
+// model.DB 未初始化时不分配序号、不落盘，只做扇出。
+func (h *Hub) Publish(taskID string, message WSProgressMessage) {
+	if model.DB != nil {
+		message.Seq = nextEventSeq(taskID)
+		persistTaskEvent(taskID, message)
+	}
+	h.events <- hubEvent{taskID: taskID, message: message}
+}
+
+// TaskSubscriber 对外暴露的订阅入口，内部委托给进程级 Hub 实现
+type TaskSubscriber struct{}
+
+var taskSubscribers = &TaskSubscriber{}
+
+// Register 订阅任务更新，返回带缓冲的消息 channel
+func (ts *TaskSubscriber) Register(taskID string, key subscriberKey) <-chan WSProgressMessage {
+	return taskHub.Register(taskID, key)
+}
+
+// Unregister 取消订阅
+func (ts *TaskSubscriber) Unregister(taskID string, key subscriberKey) {
+	taskHub.Unregister(taskID, key)
 }
 
 // GenerateWSHandler 处理生成任务的 WebSocket 连接
@@ -114,6 +200,12 @@ func GenerateWSHandler(c *gin.Context) {
 		return
 	}
 
+	// 只有任务所有者本人，或者同租户的管理员，才能订阅这个任务的事件流
+	if !canAccessTask(c, &task) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		return
+	}
+
 	// 升级为 WebSocket 连接
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -124,11 +216,18 @@ func GenerateWSHandler(c *gin.Context) {
 
 	log.Printf("[WebSocket] 客户端连接成功，任务ID: %s", taskID)
 
-	// 订阅任务更新
-	taskSubscribers.Subscribe(taskID, conn)
-	defer taskSubscribers.Unsubscribe(taskID, conn)
+	// 先订阅，再做一次性的兜底检查：订阅建立前任务可能已经终态，
+	// Hub 不会补发历史事件，所以这里仍然需要查一次数据库。
+	messages := taskSubscribers.Register(taskID, conn)
+	defer taskSubscribers.Unregister(taskID, conn)
+
+	var current model.Task
+	if err := model.DB.Where("task_id = ?", taskID).First(&current).Error; err == nil {
+		task = current
+	}
+
+	writeSubscribeAuditLog(c, taskID)
 
-	// 如果任务已经完成或失败，立即发送状态并关闭
 	if task.Status == "completed" {
 		conn.WriteJSON(WSProgressMessage{
 			Type:           "complete",
@@ -145,13 +244,6 @@ func GenerateWSHandler(c *gin.Context) {
 		return
 	}
 
-	// 启动轮询协程，监控任务状态变化
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
-	lastStatus := task.Status
-	lastCompletedAt := task.CompletedAt
-
 	// 设置读取超时和 ping/pong
 	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	conn.SetPongHandler(func(string) error {
@@ -159,10 +251,15 @@ func GenerateWSHandler(c *gin.Context) {
 		return nil
 	})
 
-	// 启动 ping 协程
 	pingTicker := time.NewTicker(30 * time.Second)
 	defer pingTicker.Stop()
 
+	// 兜底轮询：只用来应对 Hub 漏推送（例如进程重启后订阅重建）的极端情况，
+	// 不再作为主要的状态检测手段
+	reconcileTicker := time.NewTicker(reconcileInterval)
+	defer reconcileTicker.Stop()
+	lastStatus := task.Status
+
 	done := make(chan struct{})
 
 	// 监听客户端消息（主要用于检测断开）
@@ -191,65 +288,119 @@ func GenerateWSHandler(c *gin.Context) {
 				return
 			}
 
-		case <-ticker.C:
-			// 查询最新任务状态
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			if err := conn.WriteJSON(msg); err != nil {
+				log.Printf("[WebSocket] 发送消息失败: %v", err)
+				return
+			}
+			if msg.Type == "complete" || msg.Type == "error" {
+				log.Printf("[WebSocket] 任务结束，关闭连接，任务ID: %s, 类型: %s", taskID, msg.Type)
+				return
+			}
+
+		case <-reconcileTicker.C:
 			var currentTask model.Task
 			if err := model.DB.Where("task_id = ?", taskID).First(&currentTask).Error; err != nil {
-				log.Printf("[WebSocket] 查询任务失败: %v", err)
+				log.Printf("[WebSocket] 兜底轮询查询任务失败: %v", err)
 				continue
 			}
-
-			// 检查状态是否变化
-			statusChanged := currentTask.Status != lastStatus
-			completedChanged := (currentTask.CompletedAt != nil && lastCompletedAt == nil) ||
-				(currentTask.CompletedAt != nil && lastCompletedAt != nil && !currentTask.CompletedAt.Equal(*lastCompletedAt))
-
-			if statusChanged || completedChanged {
-				lastStatus = currentTask.Status
-				lastCompletedAt = currentTask.CompletedAt
-
-				switch currentTask.Status {
-				case "completed":
-					msg := WSProgressMessage{
-						Type:           "complete",
-						CompletedCount: currentTask.TotalCount,
-						TotalCount:     currentTask.TotalCount,
-						LatestImage:    buildImageInfo(&currentTask),
-					}
-					if err := conn.WriteJSON(msg); err != nil {
-						log.Printf("[WebSocket] 发送完成消息失败: %v", err)
-					}
-					log.Printf("[WebSocket] 任务完成，关闭连接，任务ID: %s", taskID)
-					return
-
-				case "failed":
-					msg := WSProgressMessage{
-						Type:    "error",
-						Message: currentTask.ErrorMessage,
-					}
-					if err := conn.WriteJSON(msg); err != nil {
-						log.Printf("[WebSocket] 发送错误消息失败: %v", err)
-					}
-					log.Printf("[WebSocket] 任务失败，关闭连接，任务ID: %s", taskID)
-					return
-
-				case "processing":
-					// 发送进度更新
-					msg := WSProgressMessage{
-						Type:           "progress",
-						CompletedCount: 0, // 单任务模式，处理中为0
-						TotalCount:     currentTask.TotalCount,
-					}
-					if err := conn.WriteJSON(msg); err != nil {
-						log.Printf("[WebSocket] 发送进度消息失败: %v", err)
-						return
-					}
-				}
+			if currentTask.Status == lastStatus {
+				continue
+			}
+			lastStatus = currentTask.Status
+
+			switch currentTask.Status {
+			case "completed":
+				conn.WriteJSON(WSProgressMessage{
+					Type:           "complete",
+					CompletedCount: currentTask.TotalCount,
+					TotalCount:     currentTask.TotalCount,
+					LatestImage:    buildImageInfo(&currentTask),
+				})
+				log.Printf("[WebSocket] 兜底轮询发现任务完成，关闭连接，任务ID: %s", taskID)
+				return
+			case "failed":
+				conn.WriteJSON(WSProgressMessage{
+					Type:    "error",
+					Message: currentTask.ErrorMessage,
+				})
+				log.Printf("[WebSocket] 兜底轮询发现任务失败，关闭连接，任务ID: %s", taskID)
+				return
 			}
 		}
 	}
 }
 
+// eventSeq 缓存每个进行中任务下一个可用的事件序号，避免每次发布都查一次
+// MAX(seq)。条目在任务进入终态后会被清理，所以这个缓存只为活跃任务占内存，
+// 不会无限增长；序号本身以数据库里已持久化的 MAX(seq) 为准，跨进程重启也
+// 不会从 0 重新计数，否则 replayEvents 的 `seq > afterSeq` 比较会失真。
+var (
+	eventSeq   = make(map[string]int64)
+	eventSeqMu sync.Mutex
+)
+
+func nextEventSeq(taskID string) int64 {
+	eventSeqMu.Lock()
+	defer eventSeqMu.Unlock()
+	if _, ok := eventSeq[taskID]; !ok {
+		eventSeq[taskID] = maxPersistedEventSeq(taskID)
+	}
+	eventSeq[taskID]++
+	return eventSeq[taskID]
+}
+
+// maxPersistedEventSeq 读取某个任务已经落盘的最大序号，首次用到这个任务时
+// 用它来接续计数，覆盖进程重启、多实例部署等场景
+func maxPersistedEventSeq(taskID string) int64 {
+	var maxSeq int64
+	model.DB.Model(&model.TaskEvent{}).Where("task_id = ?", taskID).
+		Select("COALESCE(MAX(seq), 0)").Scan(&maxSeq)
+	return maxSeq
+}
+
+// forgetEventSeq 把某个任务的序号缓存清理掉：任务进了终态之后不会再有
+// 新事件，继续占着这条缓存只是浪费内存
+func forgetEventSeq(taskID string) {
+	eventSeqMu.Lock()
+	defer eventSeqMu.Unlock()
+	delete(eventSeq, taskID)
+}
+
+// persistTaskEvent 把一次事件写入 model.TaskEvent，供 /stream 接口的
+// Last-Event-ID 断线续传读取
+func persistTaskEvent(taskID string, message WSProgressMessage) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("[WebSocket] 序列化事件失败，任务ID: %s: %v", taskID, err)
+		return
+	}
+	model.DB.Create(&model.TaskEvent{
+		TaskID:  taskID,
+		Seq:     message.Seq,
+		Type:    message.Type,
+		Payload: string(payload),
+	})
+
+	if message.Type == "complete" || message.Type == "error" {
+		forgetEventSeq(taskID)
+	}
+}
+
+// writeSubscribeAuditLog 记录一次任务订阅，供合规/排障使用
+func writeSubscribeAuditLog(c *gin.Context, taskID string) {
+	userID, _, tenantID := currentUser(c)
+	model.DB.Create(&model.AuditLog{
+		UserID:   userID,
+		TenantID: tenantID,
+		TaskID:   taskID,
+		Action:   "subscribe",
+	})
+}
+
 // buildImageInfo 构建图片信息用于 WebSocket 消息
 func buildImageInfo(task *model.Task) map[string]interface{} {
 	if task.LocalPath == "" && task.ImageURL == "" {
@@ -270,7 +421,7 @@ func buildImageInfo(task *model.Task) map[string]interface{} {
 
 // NotifyTaskProgress 通知任务进度（供 worker 调用）
 func NotifyTaskProgress(taskID string, completedCount, totalCount int, image interface{}) {
-	taskSubscribers.Broadcast(taskID, WSProgressMessage{
+	taskHub.Publish(taskID, WSProgressMessage{
 		Type:           "progress",
 		CompletedCount: completedCount,
 		TotalCount:     totalCount,
@@ -280,7 +431,7 @@ func NotifyTaskProgress(taskID string, completedCount, totalCount int, image int
 
 // NotifyTaskComplete 通知任务完成（供 worker 调用）
 func NotifyTaskComplete(taskID string, task *model.Task) {
-	taskSubscribers.Broadcast(taskID, WSProgressMessage{
+	taskHub.Publish(taskID, WSProgressMessage{
 		Type:           "complete",
 		CompletedCount: task.TotalCount,
 		TotalCount:     task.TotalCount,
@@ -290,8 +441,18 @@ func NotifyTaskComplete(taskID string, task *model.Task) {
 
 // NotifyTaskError 通知任务失败（供 worker 调用）
 func NotifyTaskError(taskID string, errMsg string) {
-	taskSubscribers.Broadcast(taskID, WSProgressMessage{
+	taskHub.Publish(taskID, WSProgressMessage{
 		Type:    "error",
 		Message: errMsg,
 	})
 }
+
+// NotifyTaskModelSelected 通知路由/加权选择最终选中了哪个模型（供 worker 调用），
+// 这样客户端能看到"到底是哪个模型产出的图片"，而不是只看到一个通用的进度事件
+func NotifyTaskModelSelected(taskID, modelID, source string) {
+	taskHub.Publish(taskID, WSProgressMessage{
+		Type:        "model",
+		ModelID:     modelID,
+		ModelSource: source,
+	})
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+// TestHubFanoutDeliversToAllSubscribers 验证同一个任务下多个订阅者
+// 都能收到 Publish 推送的事件，覆盖 Hub.run 的扇出逻辑。
+func TestHubFanoutDeliversToAllSubscribers(t *testing.T) {
+	h := newHub()
+	go h.run()
+
+	taskID := "task-fanout"
+	a := h.Register(taskID, "subscriber-a")
+	b := h.Register(taskID, "subscriber-b")
+	defer h.Unregister(taskID, "subscriber-a")
+	defer h.Unregister(taskID, "subscriber-b")
+
+	h.Publish(taskID, WSProgressMessage{Type: "progress", CompletedCount: 1, TotalCount: 2})
+
+	for name, ch := range map[string]<-chan WSProgressMessage{"a": a, "b": b} {
+		select {
+		case msg := <-ch:
+			if msg.Type != "progress" {
+				t.Fatalf("订阅者 %s 收到了意料之外的事件类型: %s", name, msg.Type)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("订阅者 %s 在超时前没有收到 Publish 推送的事件", name)
+		}
+	}
+}
+
+// TestHubBackpressureDropsInsteadOfBlocking 验证慢客户端的发送队列打满后，
+// Hub 会丢弃事件而不是阻塞在 events 主循环里，否则会拖慢同一任务下
+// 的其他订阅者。
+func TestHubBackpressureDropsInsteadOfBlocking(t *testing.T) {
+	h := newHub()
+	go h.run()
+
+	taskID := "task-backpressure"
+	slow := h.Register(taskID, "slow-subscriber")
+	defer h.Unregister(taskID, "slow-subscriber")
+
+	// 故意不从 slow 里读取，把它的发送队列灌满
+	for i := 0; i < subscriberSendBuffer+5; i++ {
+		h.Publish(taskID, WSProgressMessage{Type: "progress", CompletedCount: i})
+	}
+
+	// Publish 不应该阻塞：能执行到这里就说明 Hub 在队列满时丢弃了事件
+	select {
+	case <-slow:
+	case <-time.After(time.Second):
+		t.Fatal("慢订阅者的发送队列里应该至少有一条缓冲事件")
+	}
+}
+
+// TestHubUnregisterClosesSendChannel 验证 Unregister 会关闭订阅者的
+// 发送 channel，这样 GenerateWSHandler/TaskStreamHandler 里 `msg, ok := <-messages`
+// 的 !ok 分支能正确感知订阅已经结束。
+func TestHubUnregisterClosesSendChannel(t *testing.T) {
+	h := newHub()
+	go h.run()
+
+	taskID := "task-unregister"
+	sub := h.Register(taskID, "subscriber")
+	h.Unregister(taskID, "subscriber")
+
+	select {
+	case _, ok := <-sub:
+		if ok {
+			t.Fatal("Unregister 之后发送 channel 应该已经被关闭")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("等待发送 channel 关闭超时")
+	}
+}
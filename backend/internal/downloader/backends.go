@@ -0,0 +1,108 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// LocalBackend 把远程地址拉取到本地磁盘的指定目录下，
+// 文件名取 Job 的 GID 以避免冲突。
+type LocalBackend struct {
+	BaseDir string
+}
+
+// NewLocalBackend 创建一个写入 baseDir 的本地后端
+func NewLocalBackend(baseDir string) *LocalBackend {
+	return &LocalBackend{BaseDir: baseDir}
+}
+
+func (b *LocalBackend) Name() string { return "local" }
+
+func (b *LocalBackend) Fetch(ctx context.Context, job *Job) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, job.Source, nil)
+	if err != nil {
+		return fmt.Errorf("local backend: 构造请求失败: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("local backend: 请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("local backend: 远程返回非 200 状态码 %d", resp.StatusCode)
+	}
+
+	if err := os.MkdirAll(b.BaseDir, 0o755); err != nil {
+		return fmt.Errorf("local backend: 创建目录失败: %w", err)
+	}
+
+	dest := filepath.Join(b.BaseDir, job.GID)
+	out, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("local backend: 创建文件失败: %w", err)
+	}
+	defer out.Close()
+
+	total := resp.ContentLength
+	job.SetProgress(0, total)
+
+	counter := &progressWriter{job: job, total: total}
+	if _, err := io.Copy(out, io.TeeReader(resp.Body, counter)); err != nil {
+		return fmt.Errorf("local backend: 写入文件失败: %w", err)
+	}
+
+	return nil
+}
+
+// progressWriter 把读取到的字节数同步回 Job，供 Monitor 计算速度
+type progressWriter struct {
+	job   *Job
+	total int64
+	read  int64
+}
+
+func (p *progressWriter) Write(buf []byte) (int, error) {
+	p.read += int64(len(buf))
+	p.job.SetProgress(p.read, p.total)
+	return len(buf), nil
+}
+
+// S3Backend 和 WebDAVBackend 预留给对象存储 / WebDAV 场景，
+// 只有接口形状：Fetch 不做任何传输，总是返回错误。
+
+// S3Backend 对应 S3 兼容的对象存储；Fetch 不上传任何数据，总是返回错误
+type S3Backend struct {
+	Bucket string
+	Prefix string
+}
+
+func NewS3Backend(bucket, prefix string) *S3Backend {
+	return &S3Backend{Bucket: bucket, Prefix: prefix}
+}
+
+func (b *S3Backend) Name() string { return "s3" }
+
+func (b *S3Backend) Fetch(ctx context.Context, job *Job) error {
+	return fmt.Errorf("s3 backend: 尚未接入对象存储 SDK，bucket=%s", b.Bucket)
+}
+
+// WebDAVBackend 对应 WebDAV 服务器；Fetch 不上传任何数据，总是返回错误
+type WebDAVBackend struct {
+	Endpoint string
+}
+
+func NewWebDAVBackend(endpoint string) *WebDAVBackend {
+	return &WebDAVBackend{Endpoint: endpoint}
+}
+
+func (b *WebDAVBackend) Name() string { return "webdav" }
+
+func (b *WebDAVBackend) Fetch(ctx context.Context, job *Job) error {
+	return fmt.Errorf("webdav backend: 尚未接入 WebDAV 客户端，endpoint=%s", b.Endpoint)
+}
@@ -0,0 +1,269 @@
+// Package downloader 实现一个 aria2 风格的异步传输队列：把生成参数里
+// 引用的远程参考图拉取到本地磁盘，作为后台任务执行，不阻塞生成 goroutine。
+// 能真正完成传输的只有 "local" 后端；S3/WebDAV 后端的 Fetch 总是返回错误，
+// 生成结果也不经过这个包写入任何存储后端。
+package downloader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"image-gen-service/internal/config"
+	"image-gen-service/internal/model"
+	"image-gen-service/internal/worker"
+)
+
+// defaultLocalBaseDir 是没有在配置里显式指定时，本地后端落盘的默认目录
+const defaultLocalBaseDir = "./data/downloads"
+
+// Backend 负责把一个 Job 落地。目前唯一可用的实现 LocalBackend 从远程
+// 地址拉取到本地；S3Backend/WebDAVBackend 只有接口形状，不做任何传输。
+type Backend interface {
+	Name() string
+	Fetch(ctx context.Context, job *Job) error
+}
+
+// Job 是一次传输任务的运行时状态，持久化快照保存在 model.Download 里
+type Job struct {
+	GID         string
+	TaskID      string
+	UserID      string
+	Source      string
+	BackendName string
+
+	mu             sync.Mutex
+	downloadedSize int64
+	totalSize      int64
+}
+
+// Progress 返回当前的进度快照，Backend 实现在拉取过程中周期性调用
+func (j *Job) Progress() (downloaded, total int64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.downloadedSize, j.totalSize
+}
+
+// SetProgress 由 Backend 在读写过程中调用，更新字节计数
+func (j *Job) SetProgress(downloaded, total int64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.downloadedSize = downloaded
+	j.totalSize = total
+}
+
+const (
+	// queueSize 是等待处理的传输任务缓冲区大小
+	queueSize = 256
+	// workerCount 是并发处理传输任务的 goroutine 数量
+	workerCount = 4
+	// monitorInterval 是 Monitor 刷新进行中任务速度/进度的周期
+	monitorInterval = 1 * time.Second
+)
+
+var (
+	backends   = make(map[string]Backend)
+	backendsMu sync.RWMutex
+
+	queue      = make(chan *Job, queueSize)
+	startOnce  sync.Once
+	inFlight   = make(map[string]*Job) // gid -> job，供 Monitor 轮询
+	inFlightMu sync.Mutex
+)
+
+// RegisterBackend 注册一个存储后端，重复注册以后者为准
+func RegisterBackend(b Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[b.Name()] = b
+}
+
+func getBackend(name string) Backend {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	return backends[name]
+}
+
+// Start 启动传输 worker 池和 Monitor，注册默认的 "local" 后端，并把自己注册进
+// worker.Downloads，这样 worker.RunGenerate 才能真正把参考图丢进传输队列。
+// 进程内只应初始化一次，内部用 sync.Once 保护，重复调用是安全的。
+//
+// worker 包本身不能调用这个函数（downloader 反过来依赖 worker.Notifier/
+// TaskDownloader，互相 import 会成环），所以真正的调用方是
+// api.CreateTaskHandler：收到生成请求时会先调用一次 Start，再把任务交给
+// worker.RunGenerate，这样 enqueueReferenceImages 跑的时候 worker.Downloads
+// 已经就绪。Enqueue 自己也会调用 Start 兜底，但那只在 worker.Downloads
+// 已经非 nil（也就是已经调用过一次 Start）之后才会被触发。
+// S3/WebDAV 等后端由各自的配置在加载时通过 RegisterBackend 追加注册，
+// 这里只保证 "local" 开箱即用。
+func Start() {
+	startOnce.Do(func() {
+		baseDir := config.GlobalConfig.DownloadBaseDir
+		if baseDir == "" {
+			baseDir = defaultLocalBaseDir
+		}
+		RegisterBackend(NewLocalBackend(baseDir))
+
+		for i := 0; i < workerCount; i++ {
+			go transferWorker()
+		}
+		go Monitor()
+		worker.Downloads = workerDownloader{}
+		log.Printf("[Downloader] 已启动 %d 个传输 worker，本地后端目录: %s", workerCount, baseDir)
+	})
+}
+
+// workerDownloader 把包级 Enqueue 适配成 worker.TaskDownloader 接口
+type workerDownloader struct{}
+
+func (workerDownloader) Enqueue(source, backendName, taskID, userID string) error {
+	_, err := Enqueue(source, backendName, taskID, userID)
+	return err
+}
+
+// Enqueue 创建一条 model.Download 记录并投递到传输队列，立即返回不阻塞调用方
+func Enqueue(source, backendName, taskID, userID string) (*model.Download, error) {
+	Start()
+
+	if getBackend(backendName) == nil {
+		return nil, fmt.Errorf("downloader: 未注册的后端 %q", backendName)
+	}
+
+	gid, err := newGID()
+	if err != nil {
+		return nil, fmt.Errorf("downloader: 生成 GID 失败: %w", err)
+	}
+
+	download := &model.Download{
+		GID:    gid,
+		TaskID: taskID,
+		UserID: userID,
+		Status: model.DownloadStatusPending,
+		Source: source,
+	}
+	if err := model.DB.Create(download).Error; err != nil {
+		return nil, fmt.Errorf("downloader: 写入 Download 记录失败: %w", err)
+	}
+
+	job := &Job{GID: gid, TaskID: taskID, UserID: userID, Source: source, BackendName: backendName}
+	inFlightMu.Lock()
+	inFlight[gid] = job
+	inFlightMu.Unlock()
+
+	queue <- job
+	return download, nil
+}
+
+func transferWorker() {
+	for job := range queue {
+		runJob(job)
+	}
+}
+
+func runJob(job *Job) {
+	model.DB.Model(&model.Download{}).Where("gid = ?", job.GID).
+		Update("status", model.DownloadStatusActive)
+
+	backend := getBackend(job.BackendName)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	err := backend.Fetch(ctx, job)
+
+	inFlightMu.Lock()
+	delete(inFlight, job.GID)
+	inFlightMu.Unlock()
+
+	downloaded, total := job.Progress()
+	now := time.Now()
+
+	if err != nil {
+		model.DB.Model(&model.Download{}).Where("gid = ?", job.GID).Updates(map[string]interface{}{
+			"status":          model.DownloadStatusError,
+			"downloaded_size": downloaded,
+			"total_size":      total,
+			"error_message":   err.Error(),
+		})
+		notify(job.TaskID, job.GID, model.DownloadStatusError, downloaded, total, err.Error())
+		return
+	}
+
+	model.DB.Model(&model.Download{}).Where("gid = ?", job.GID).Updates(map[string]interface{}{
+		"status":          model.DownloadStatusDone,
+		"downloaded_size": downloaded,
+		"total_size":      total,
+		"completed_at":    &now,
+	})
+	notify(job.TaskID, job.GID, model.DownloadStatusDone, downloaded, total, "")
+}
+
+// Monitor 周期性地巡检进行中的传输任务，刷新速度与进度并推送通知，
+// 模仿 aria2 的 monitor/transfer 模型：真正的读写在 Backend.Fetch 里异步进行，
+// 这里只负责把运行时状态同步给数据库和 WebSocket 客户端。
+func Monitor() {
+	ticker := time.NewTicker(monitorInterval)
+	defer ticker.Stop()
+
+	lastSeen := make(map[string]int64) // gid -> 上一次采样的字节数
+
+	for range ticker.C {
+		inFlightMu.Lock()
+		snapshot := make([]*Job, 0, len(inFlight))
+		for _, job := range inFlight {
+			snapshot = append(snapshot, job)
+		}
+		inFlightMu.Unlock()
+
+		for _, job := range snapshot {
+			downloaded, total := job.Progress()
+			speed := downloaded - lastSeen[job.GID]
+			if speed < 0 {
+				speed = 0
+			}
+			lastSeen[job.GID] = downloaded
+
+			model.DB.Model(&model.Download{}).Where("gid = ?", job.GID).Updates(map[string]interface{}{
+				"downloaded_size": downloaded,
+				"total_size":      total,
+				"speed":           speed,
+			})
+			notify(job.TaskID, job.GID, model.DownloadStatusActive, downloaded, total, "")
+		}
+	}
+}
+
+// notify 把下载进度接到已有的 worker.TaskNotifier 上，这样 WebSocket
+// 客户端在同一条任务的事件流里能同时看到生成进度和下载进度
+func notify(taskID, gid string, status model.DownloadStatus, downloaded, total int64, errMsg string) {
+	if taskID == "" || worker.Notifier == nil {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"kind":           "download",
+		"gid":            gid,
+		"status":         status,
+		"downloadedSize": downloaded,
+		"totalSize":      total,
+	}
+
+	if status == model.DownloadStatusError {
+		worker.Notifier.NotifyProgress(taskID, 0, 0, payload)
+		log.Printf("[Downloader] 传输失败 gid=%s task=%s: %s", gid, taskID, errMsg)
+		return
+	}
+
+	worker.Notifier.NotifyProgress(taskID, 0, 0, payload)
+}
+
+func newGID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,51 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestStartRegistersLocalBackend 验证 Start 会自动注册 "local" 后端。
+// enqueueReferenceImages 硬编码了这个后端名字，如果没有人调用 RegisterBackend，
+// 每一次 Enqueue 都会以 "未注册的后端" 失败。
+func TestStartRegistersLocalBackend(t *testing.T) {
+	Start()
+	if getBackend("local") == nil {
+		t.Fatal(`Start 之后 "local" 后端应该已经注册`)
+	}
+}
+
+// TestLocalBackendFetchWritesFile 验证 LocalBackend 能把远程地址的内容
+// 落盘到 BaseDir 下，并同步更新 Job 的进度。
+func TestLocalBackendFetchWritesFile(t *testing.T) {
+	const body = "hello-reference-image"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	backend := NewLocalBackend(dir)
+	job := &Job{GID: "test-gid", Source: srv.URL}
+
+	if err := backend.Fetch(context.Background(), job); err != nil {
+		t.Fatalf("Fetch 失败: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, job.GID))
+	if err != nil {
+		t.Fatalf("读取落盘文件失败: %v", err)
+	}
+	if string(data) != body {
+		t.Fatalf("文件内容 = %q, want %q", data, body)
+	}
+
+	downloaded, total := job.Progress()
+	if downloaded != int64(len(body)) || total != int64(len(body)) {
+		t.Fatalf("Progress() = (%d, %d), want (%d, %d)", downloaded, total, len(body), len(body))
+	}
+}
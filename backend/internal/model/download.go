@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// DownloadStatus 描述一次传输任务所处的阶段
+type DownloadStatus string
+
+const (
+	DownloadStatusPending DownloadStatus = "pending"
+	DownloadStatusActive  DownloadStatus = "active"
+	DownloadStatusPaused  DownloadStatus = "paused"
+	DownloadStatusDone    DownloadStatus = "complete"
+	DownloadStatusError   DownloadStatus = "error"
+)
+
+// Download 对应 downloader 包里的一次传输任务（参考图、生成结果的落盘/上传）
+type Download struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	GID            string         `gorm:"uniqueIndex;size:64" json:"gid"` // aria2 风格的任务句柄
+	TaskID         string         `gorm:"index;size:64" json:"taskId"`    // 关联的生成任务，可为空（独立下载）
+	UserID         string         `gorm:"index;size:64" json:"userId"`
+	Status         DownloadStatus `gorm:"size:16" json:"status"`
+	Source         string         `gorm:"size:1024" json:"source"` // 远程地址，或本地/对象存储写入目标的来源标识
+	Path           string         `gorm:"size:1024" json:"path"`   // 落盘路径，或对象存储 key
+	TotalSize      int64          `json:"totalSize"`
+	DownloadedSize int64          `json:"downloadedSize"`
+	Speed          int64          `json:"speed"` // 字节/秒，由 Monitor 周期性刷新
+	ErrorMessage   string         `gorm:"size:1024" json:"errorMessage,omitempty"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+	CompletedAt    *time.Time     `json:"completedAt,omitempty"`
+}
+
+// TableName 显式指定表名，避免 GORM 默认复数化规则产生歧义
+func (Download) TableName() string {
+	return "downloads"
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// TaskEvent 是某个任务事件流里的一条持久化记录，Seq 在任务维度单调递增。
+// /api/tasks/:task_id/stream 用它配合 Last-Event-ID 实现断线续传。
+type TaskEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TaskID    string    `gorm:"index:idx_task_event_seq;size:64" json:"taskId"`
+	Seq       int64     `gorm:"index:idx_task_event_seq" json:"seq"`
+	Type      string    `gorm:"size:16" json:"type"`
+	Payload   string    `gorm:"type:text" json:"payload"` // 序列化后的 WSProgressMessage
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TableName 显式指定表名，避免 GORM 默认复数化规则产生歧义
+func (TaskEvent) TableName() string {
+	return "task_events"
+}
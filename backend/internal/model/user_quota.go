@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// UserQuota 记录某个租户下某个用户在某个自然月的生图配额用量。
+// Month 采用 "2006-01" 格式，配合 (tenant_id, user_id, month) 唯一索引按月滚动。
+type UserQuota struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     string    `gorm:"uniqueIndex:idx_user_quota_period;size:64" json:"userId"`
+	TenantID   string    `gorm:"uniqueIndex:idx_user_quota_period;size:64" json:"tenantId"`
+	Month      string    `gorm:"uniqueIndex:idx_user_quota_period;size:7" json:"month"`
+	ImageCount int       `json:"imageCount"`
+	ImageLimit int       `json:"imageLimit"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// TableName 显式指定表名，避免 GORM 默认复数化规则产生歧义
+func (UserQuota) TableName() string {
+	return "user_quotas"
+}
+
+// AuditLog 记录每一次生成请求的操作审计，供合规/排障使用
+type AuditLog struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    string    `gorm:"index;size:64" json:"userId"`
+	TenantID  string    `gorm:"index;size:64" json:"tenantId"`
+	TaskID    string    `gorm:"index;size:64" json:"taskId"`
+	Action    string    `gorm:"size:32" json:"action"` // 如 "generate"、"subscribe"
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TableName 显式指定表名，避免 GORM 默认复数化规则产生歧义
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
@@ -1,8 +1,11 @@
 package provider
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"image-gen-service/internal/model"
+	"math/rand"
 	"strings"
 )
 
@@ -11,6 +14,7 @@ type ModelPurpose string
 const (
 	PurposeImage ModelPurpose = "image"
 	PurposeChat  ModelPurpose = "chat"
+	PurposeEdit  ModelPurpose = "edit"
 )
 
 type ModelResolveOptions struct {
@@ -21,42 +25,244 @@ type ModelResolveOptions struct {
 	Config       *model.ProviderConfig
 }
 
+// ModelSource 描述一次模型解析的来源，以及（如果走的是路由）被淘汰的候选链，
+// 这样 WSProgressMessage 能把"最终是哪个模型产出的图片"透出给前端。
+type ModelSource struct {
+	Origin        string   `json:"origin"`                  // "request" / "params" / "config" / "default" / "route"
+	FallbackChain []string `json:"fallbackChain,omitempty"` // 路由选中之外，仍可重试的候选 ID，按顺序
+	Attempted     []string `json:"attempted,omitempty"`     // 实际尝试过的 ID（RouteModel 重试后才会有多个）
+}
+
 type ModelResolveResult struct {
 	ID     string
-	Source string
+	Source ModelSource
+}
+
+// ModelEntry 是 ProviderConfig.Models JSON 里单个模型的完整描述
+type ModelEntry struct {
+	ID           string       `json:"id"`
+	Name         string       `json:"name,omitempty"`
+	Default      bool         `json:"default,omitempty"`
+	Weight       int          `json:"weight,omitempty"`       // 加权随机选择的权重，<=0 按 1 处理
+	Purpose      ModelPurpose `json:"purpose,omitempty"`      // 为空视为不限 purpose，任何请求都可选中
+	Capabilities []string     `json:"capabilities,omitempty"` // 如 "ratio:16:9"、"ref-image"、"industry:ecom"
+	FallbackIDs  []string     `json:"fallback_ids,omitempty"` // Generate 失败时按顺序重试的候选
+}
+
+// RouteRequest 是参与路由过滤的请求维度，与 templates 接口过滤的轴保持一致
+type RouteRequest struct {
+	Ratio    string
+	Material string
+	Industry string
 }
 
 func ResolveModelID(opts ModelResolveOptions) ModelResolveResult {
 	if trimmed := strings.TrimSpace(opts.RequestModel); trimmed != "" {
-		return ModelResolveResult{ID: trimmed, Source: "request"}
+		return ModelResolveResult{ID: trimmed, Source: ModelSource{Origin: "request"}}
 	}
 
 	if opts.Params != nil {
 		if v, ok := opts.Params["model_id"].(string); ok {
 			if trimmed := strings.TrimSpace(v); trimmed != "" {
-				return ModelResolveResult{ID: trimmed, Source: "params"}
+				return ModelResolveResult{ID: trimmed, Source: ModelSource{Origin: "params"}}
 			}
 		}
 		if v, ok := opts.Params["model"].(string); ok {
 			if trimmed := strings.TrimSpace(v); trimmed != "" {
-				return ModelResolveResult{ID: trimmed, Source: "params"}
+				return ModelResolveResult{ID: trimmed, Source: ModelSource{Origin: "params"}}
 			}
 		}
 	}
 
 	if opts.Config != nil {
 		if id := pickModelFromModels(opts.Config.Models); id != "" {
-			return ModelResolveResult{ID: id, Source: "config"}
+			return ModelResolveResult{ID: id, Source: ModelSource{Origin: "config"}}
 		}
 	}
 
 	if id := defaultModelForProvider(opts.ProviderName, opts.Purpose); id != "" {
-		return ModelResolveResult{ID: id, Source: "default"}
+		return ModelResolveResult{ID: id, Source: ModelSource{Origin: "default"}}
 	}
 
 	return ModelResolveResult{}
 }
 
+// RouteModel 在 ResolveModelID 的基础上加入完整的路由策略：按请求的
+// ratio/material/industry 过滤出 Config.Models 里能力匹配的候选，在候选间
+// 做加权随机选择，并在 Provider.Generate 失败时按 fallback_ids 依次重试，
+// 直到用尽候选或某个候选成功为止。
+func RouteModel(ctx context.Context, opts ModelResolveOptions, request RouteRequest, params map[string]interface{}) (*ProviderResult, ModelResolveResult, error) {
+	// 请求/参数里显式指定的模型优先级高于路由，行为与 ResolveModelID 一致
+	if explicit := ResolveModelID(opts); explicit.Source.Origin == "request" || explicit.Source.Origin == "params" {
+		result, err := generateWithModel(ctx, opts.ProviderName, explicit.ID, params)
+		explicit.Source.Attempted = []string{explicit.ID}
+		return result, explicit, err
+	}
+
+	entries := parseModelEntries(opts.Config)
+	eligible := filterEligible(entries, opts.Purpose, request)
+	if len(eligible) == 0 {
+		// 没有任何候选满足过滤条件，退回到原先的默认值逻辑
+		fallback := ResolveModelID(opts)
+		if fallback.ID == "" {
+			return nil, fallback, fmt.Errorf("provider: 没有可用的模型候选")
+		}
+		result, err := generateWithModel(ctx, opts.ProviderName, fallback.ID, params)
+		fallback.Source.Attempted = []string{fallback.ID}
+		return result, fallback, err
+	}
+
+	chosen := weightedPick(eligible)
+	chain := buildFallbackChain(chosen, eligible)
+
+	source := ModelSource{Origin: "route", FallbackChain: chain[1:]}
+	var lastErr error
+	for _, id := range chain {
+		source.Attempted = append(source.Attempted, id)
+		result, err := generateWithModel(ctx, opts.ProviderName, id, params)
+		if err == nil {
+			return result, ModelResolveResult{ID: id, Source: source}, nil
+		}
+		lastErr = err
+	}
+
+	return nil, ModelResolveResult{ID: chosen.ID, Source: source}, fmt.Errorf("provider: 所有候选模型均失败: %w", lastErr)
+}
+
+func generateWithModel(ctx context.Context, providerName, modelID string, params map[string]interface{}) (*ProviderResult, error) {
+	p := GetProvider(providerName)
+	if p == nil {
+		return nil, fmt.Errorf("provider: 未找到已注册的 provider %q", providerName)
+	}
+
+	callParams := make(map[string]interface{}, len(params)+1)
+	for k, v := range params {
+		callParams[k] = v
+	}
+	callParams["model_id"] = modelID
+
+	return p.Generate(ctx, callParams)
+}
+
+// parseModelEntries 解析 ProviderConfig.Models JSON，兼容只有 id/default 两个字段的旧格式
+func parseModelEntries(cfg *model.ProviderConfig) []ModelEntry {
+	if cfg == nil {
+		return nil
+	}
+	raw := strings.TrimSpace(cfg.Models)
+	if raw == "" {
+		return nil
+	}
+	var entries []ModelEntry
+	if err := json.Unmarshal([]byte(raw), &entries); err != nil {
+		return nil
+	}
+	return entries
+}
+
+// filterEligible 按请求的能力轴（ratio/material/industry）过滤候选，
+// 与 templates.FilterItems 过滤模板时使用的轴保持一致；没有声明任何
+// capabilities 的候选视为通配，始终保留。
+func filterEligible(entries []ModelEntry, purpose ModelPurpose, request RouteRequest) []ModelEntry {
+	var eligible []ModelEntry
+	for _, entry := range entries {
+		if entry.Purpose != "" && purpose != "" && entry.Purpose != purpose {
+			continue
+		}
+		if len(entry.Capabilities) == 0 {
+			eligible = append(eligible, entry)
+			continue
+		}
+		if matchesCapabilities(entry.Capabilities, request) {
+			eligible = append(eligible, entry)
+		}
+	}
+	return eligible
+}
+
+func matchesCapabilities(capabilities []string, request RouteRequest) bool {
+	want := map[string]string{
+		"ratio":    request.Ratio,
+		"material": request.Material,
+		"industry": request.Industry,
+	}
+	for axis, value := range want {
+		if value == "" {
+			continue
+		}
+		if !hasCapability(capabilities, axis, value) && !declaresAxis(capabilities, axis) {
+			// 候选没有为这个轴声明任何值，视为不限制
+			continue
+		}
+		if declaresAxis(capabilities, axis) && !hasCapability(capabilities, axis, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func declaresAxis(capabilities []string, axis string) bool {
+	prefix := axis + ":"
+	for _, c := range capabilities {
+		if strings.HasPrefix(c, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasCapability(capabilities []string, axis, value string) bool {
+	needle := axis + ":" + value
+	for _, c := range capabilities {
+		if strings.EqualFold(c, needle) {
+			return true
+		}
+	}
+	return false
+}
+
+// weightedPick 在候选间做加权随机选择，权重未设置或非正数按 1 处理
+func weightedPick(entries []ModelEntry) ModelEntry {
+	total := 0
+	for _, e := range entries {
+		total += normalizedWeight(e)
+	}
+	if total <= 0 {
+		return entries[0]
+	}
+
+	pick := rand.Intn(total)
+	for _, e := range entries {
+		pick -= normalizedWeight(e)
+		if pick < 0 {
+			return e
+		}
+	}
+	return entries[len(entries)-1]
+}
+
+func normalizedWeight(e ModelEntry) int {
+	if e.Weight <= 0 {
+		return 1
+	}
+	return e.Weight
+}
+
+// buildFallbackChain 以被选中的候选为首，后面接上它声明的 fallback_ids，
+// 重复的 ID 会被跳过；fallback_ids 不按 eligible 过滤，不满足请求能力的 ID 也会进入链中
+func buildFallbackChain(chosen ModelEntry, _ []ModelEntry) []string {
+	chain := []string{chosen.ID}
+	seen := map[string]bool{chosen.ID: true}
+	for _, id := range chosen.FallbackIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		chain = append(chain, id)
+	}
+	return chain
+}
+
 func pickModelFromModels(models string) string {
 	models = strings.TrimSpace(models)
 	if models == "" {
@@ -0,0 +1,85 @@
+package provider
+
+import "testing"
+
+func TestFilterEligibleMatchesCapabilityAxis(t *testing.T) {
+	entries := []ModelEntry{
+		{ID: "wide", Capabilities: []string{"ratio:16:9"}},
+		{ID: "square", Capabilities: []string{"ratio:1:1"}},
+		{ID: "wildcard"},
+	}
+
+	got := filterEligible(entries, PurposeImage, RouteRequest{Ratio: "16:9"})
+
+	ids := make(map[string]bool, len(got))
+	for _, e := range got {
+		ids[e.ID] = true
+	}
+	if !ids["wide"] {
+		t.Error("声明了匹配 ratio 的候选应该保留")
+	}
+	if !ids["wildcard"] {
+		t.Error("没有声明 ratio 能力的候选应该视为通配，始终保留")
+	}
+	if ids["square"] {
+		t.Error("声明了不匹配 ratio 的候选应该被过滤掉")
+	}
+}
+
+func TestFilterEligibleByPurpose(t *testing.T) {
+	entries := []ModelEntry{
+		{ID: "img", Purpose: PurposeImage},
+		{ID: "chat", Purpose: PurposeChat},
+		{ID: "unset"},
+	}
+
+	got := filterEligible(entries, PurposeChat, RouteRequest{})
+
+	ids := make(map[string]bool, len(got))
+	for _, e := range got {
+		ids[e.ID] = true
+	}
+	if ids["img"] {
+		t.Error("purpose 不匹配的候选不应该入选")
+	}
+	if !ids["chat"] || !ids["unset"] {
+		t.Error("purpose 匹配或未声明 purpose 的候选应该入选")
+	}
+}
+
+func TestWeightedPickSingleEntry(t *testing.T) {
+	entries := []ModelEntry{{ID: "only"}}
+	if got := weightedPick(entries); got.ID != "only" {
+		t.Fatalf("weightedPick() = %q, want %q", got.ID, "only")
+	}
+}
+
+func TestWeightedPickOnlyReturnsEligibleEntries(t *testing.T) {
+	entries := []ModelEntry{
+		{ID: "a", Weight: 1},
+		{ID: "b", Weight: 5},
+	}
+
+	for i := 0; i < 50; i++ {
+		got := weightedPick(entries)
+		if got.ID != "a" && got.ID != "b" {
+			t.Fatalf("weightedPick() 返回了不在候选列表里的结果: %q", got.ID)
+		}
+	}
+}
+
+func TestBuildFallbackChainDropsDuplicates(t *testing.T) {
+	chosen := ModelEntry{ID: "primary", FallbackIDs: []string{"primary", "backup", "backup"}}
+
+	chain := buildFallbackChain(chosen, nil)
+
+	want := []string{"primary", "backup"}
+	if len(chain) != len(want) {
+		t.Fatalf("buildFallbackChain() = %v, want %v", chain, want)
+	}
+	for i, id := range want {
+		if chain[i] != id {
+			t.Fatalf("buildFallbackChain()[%d] = %q, want %q", i, chain[i], id)
+		}
+	}
+}
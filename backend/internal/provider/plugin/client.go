@@ -0,0 +1,100 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Client 是到一个 Provider 插件 sidecar 的连接，封装了 provider.proto
+// 里的三个 RPC，调用方不需要关心底层是 unix socket 还是 tcp。
+type Client struct {
+	conn   *grpc.ClientConn
+	target string
+}
+
+// Dial 连接一个插件 sidecar。target 既可以是 "unix:///path/to.sock"，
+// 也可以是 "host:port"。
+func Dial(ctx context.Context, target string) (*Client, error) {
+	conn, err := grpc.DialContext(ctx, target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("plugin: 连接 %s 失败: %w", target, err)
+	}
+	return &Client{conn: conn, target: target}, nil
+}
+
+// Close 断开与 sidecar 的连接
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Target 返回这个 Client 拨号时用的地址，供日志/调试使用
+func (c *Client) Target() string {
+	return c.target
+}
+
+// Describe 查询插件声明的名字与能力
+func (c *Client) Describe(ctx context.Context) (*DescribeResponse, error) {
+	resp := &DescribeResponse{}
+	if err := c.conn.Invoke(ctx, serviceName+"/Describe", &DescribeRequest{}, resp, grpc.CallContentSubtype(jsonCodecName)); err != nil {
+		return nil, fmt.Errorf("plugin: Describe 调用失败 (%s): %w", c.target, err)
+	}
+	return resp, nil
+}
+
+// ValidateParams 在调用 Generate 之前做一次快速校验
+func (c *Client) ValidateParams(ctx context.Context, params map[string]interface{}) error {
+	resp := &ValidateParamsResponse{}
+	req := &ValidateParamsRequest{Params: params}
+	if err := c.conn.Invoke(ctx, serviceName+"/ValidateParams", req, resp, grpc.CallContentSubtype(jsonCodecName)); err != nil {
+		return fmt.Errorf("plugin: ValidateParams 调用失败 (%s): %w", c.target, err)
+	}
+	if !resp.Valid {
+		return fmt.Errorf("plugin: 参数校验未通过: %s", resp.Reason)
+	}
+	return nil
+}
+
+// generateStreamDesc 描述 Generate 这个 server-streaming RPC
+var generateStreamDesc = &grpc.StreamDesc{StreamName: "Generate", ServerStreams: true}
+
+// Generate 发起一次生成调用，返回的 channel 会在收到 done=true 的分片后关闭
+func (c *Client) Generate(ctx context.Context, params map[string]interface{}) (<-chan GenerateChunk, error) {
+	stream, err := c.conn.NewStream(ctx, generateStreamDesc, serviceName+"/Generate", grpc.CallContentSubtype(jsonCodecName))
+	if err != nil {
+		return nil, fmt.Errorf("plugin: Generate 调用失败 (%s): %w", c.target, err)
+	}
+	if err := stream.SendMsg(&GenerateRequest{Params: params}); err != nil {
+		return nil, fmt.Errorf("plugin: 发送 Generate 请求失败 (%s): %w", c.target, err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, fmt.Errorf("plugin: 关闭 Generate 请求流失败 (%s): %w", c.target, err)
+	}
+
+	out := make(chan GenerateChunk, 4)
+	go func() {
+		defer close(out)
+		for {
+			var chunk GenerateChunk
+			if err := stream.RecvMsg(&chunk); err != nil {
+				// sidecar 崩溃、连接中断或生成中途失败都会在这里表现为非 nil
+				// 的 RecvMsg 错误：正常结束总是先收到一个 Done=true 的 chunk
+				// 并在上面 return，不会再走到下一次 RecvMsg。把错误包装成
+				// 最后一个 chunk 传出去，否则 out 会被直接 close，调用方的
+				// `for chunk := range chunks` 会把截断的生成误判为成功。
+				out <- GenerateChunk{Done: true, Error: fmt.Sprintf("plugin: 读取 Generate 流失败 (%s): %v", c.target, err)}
+				return
+			}
+			out <- chunk
+			if chunk.Done {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
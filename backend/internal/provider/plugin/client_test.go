@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// crashingGenerateHandler 模拟一个发出一个分片之后中途崩溃/断线的 sidecar：
+// 不通过 SendMsg(&GenerateChunk{Done: true, Error: ...}) 正常收尾，而是直接
+// 让 handler 返回一个错误，这样客户端的 stream.RecvMsg 会拿到非 nil、非
+// Done 分片的错误。
+func crashingGenerateHandler(_ interface{}, stream grpc.ServerStream) error {
+	req := &GenerateRequest{}
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	if err := stream.SendMsg(&GenerateChunk{ImageChunk: []byte("partial")}); err != nil {
+		return err
+	}
+	return errors.New("sidecar 模拟崩溃")
+}
+
+var crashingServiceDesc = grpc.ServiceDesc{
+	ServiceName: "provider.ProviderPlugin",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{
+		{StreamName: "Generate", Handler: crashingGenerateHandler, ServerStreams: true},
+	},
+}
+
+func TestGeneratePropagatesMidStreamError(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	defer lis.Close()
+
+	server := grpc.NewServer()
+	server.RegisterService(&crashingServiceDesc, nil)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := Dial(ctx, lis.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial 失败: %v", err)
+	}
+	defer client.Close()
+
+	chunks, err := client.Generate(ctx, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Generate 失败: %v", err)
+	}
+
+	var gotPartial bool
+	var gotError string
+	for chunk := range chunks {
+		if len(chunk.ImageChunk) > 0 {
+			gotPartial = true
+		}
+		if chunk.Error != "" {
+			gotError = chunk.Error
+		}
+	}
+
+	if !gotPartial {
+		t.Error("应该先收到崩溃前发出的那个分片")
+	}
+	if gotError == "" {
+		t.Error("Generate 的 channel 关闭前应该带上一个 Error 分片，而不是悄悄 close")
+	}
+}
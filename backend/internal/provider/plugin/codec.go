@@ -0,0 +1,32 @@
+package plugin
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// jsonCodecName 是注册到 google.golang.org/grpc/encoding 下的编码名字，
+// 通过 grpc.CallContentSubtype("json") 在每次调用时选用。
+const jsonCodecName = "json"
+
+// jsonCodec 让插件 sidecar 可以用普通的 JSON 结构体作为 gRPC 消息体，
+// 这样接入一个新的 provider 插件不需要先搭好 protobuf/protoc 工具链，
+// 跑通 provider.proto 里描述的最小契约即可。
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return jsonCodecName
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
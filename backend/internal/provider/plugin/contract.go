@@ -0,0 +1,35 @@
+package plugin
+
+// serviceName 是 provider.proto 里 ProviderPlugin 服务的完整 gRPC 方法前缀
+const serviceName = "/provider.ProviderPlugin"
+
+// 下面的类型是 provider.proto 契约在 Go 侧的镜像，通过 jsonCodec 在线上传输。
+// 改动任意一个都需要同步更新 provider.proto 里的注释。
+
+type DescribeRequest struct{}
+
+type DescribeResponse struct {
+	Name         string   `json:"name"`
+	Capabilities []string `json:"capabilities"`
+	Purposes     []string `json:"purposes"`
+}
+
+type ValidateParamsRequest struct {
+	Params map[string]interface{} `json:"params"`
+}
+
+type ValidateParamsResponse struct {
+	Valid  bool   `json:"valid"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type GenerateRequest struct {
+	Params map[string]interface{} `json:"params"`
+}
+
+type GenerateChunk struct {
+	ImageChunk []byte                 `json:"imageChunk,omitempty"`
+	Done       bool                   `json:"done"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
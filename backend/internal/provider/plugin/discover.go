@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// dialTimeout 是单个 sidecar 的拨号超时。每个 target 都用自己独立的
+// deadline，这样一个卡住的 socket 只会拖慢自己，不会拖累后面还没扫描到的
+// sidecar，也不会让 Discover 在调用方传入 context.Background() 时永久阻塞。
+const dialTimeout = 5 * time.Second
+
+// Discover 找出所有可以连接的插件 sidecar：先扫描 socketDir 下的 *.sock
+// 文件，再加上配置里显式列出的 endpoints（host:port）。单个 sidecar 拨号
+// 失败（或超时）不会导致整体失败，调用方只会拿到能连上的那一部分。
+func Discover(ctx context.Context, socketDir string, endpoints []string) ([]*Client, error) {
+	var targets []string
+
+	if socketDir != "" {
+		matches, err := filepath.Glob(filepath.Join(socketDir, "*.sock"))
+		if err != nil {
+			return nil, fmt.Errorf("plugin: 扫描 %s 失败: %w", socketDir, err)
+		}
+		for _, m := range matches {
+			abs, err := filepath.Abs(m)
+			if err != nil {
+				log.Printf("plugin: 解析 %s 的绝对路径失败: %v", m, err)
+				continue
+			}
+			// "unix:" + 绝对路径是 grpc target 解析里唯一无歧义的写法，
+			// "unix://" + 相对路径会被当成 "authority/endpoint" 解析，连不上。
+			targets = append(targets, "unix:"+abs)
+		}
+	}
+
+	for _, ep := range endpoints {
+		if ep != "" {
+			targets = append(targets, ep)
+		}
+	}
+
+	clients := make([]*Client, 0, len(targets))
+	for _, target := range targets {
+		dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+		client, err := Dial(dialCtx, target)
+		cancel()
+		if err != nil {
+			log.Printf("plugin: 连接 %s 失败，跳过: %v", target, err)
+			continue
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"image-gen-service/internal/provider/plugin"
+)
+
+// pluginAdapter 让一个 gRPC 插件 sidecar 看起来就是一个普通的 Provider，
+// Registry、ResolveModelID、RouteModel 都不需要关心它是进程内实现还是
+// 一个独立进程。
+type pluginAdapter struct {
+	client       *plugin.Client
+	name         string
+	capabilities []string
+}
+
+// newPluginAdapter 连接后立即调用一次 Describe，用返回的名字和能力
+// 注册进 Registry
+func newPluginAdapter(ctx context.Context, client *plugin.Client) (*pluginAdapter, error) {
+	desc, err := client.Describe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if desc.Name == "" {
+		return nil, fmt.Errorf("provider: 插件 %s 的 Describe 返回了空的 Name", client.Target())
+	}
+	return &pluginAdapter{client: client, name: desc.Name, capabilities: desc.Capabilities}, nil
+}
+
+func (a *pluginAdapter) Name() string { return a.name }
+
+func (a *pluginAdapter) ValidateParams(params map[string]interface{}) error {
+	return a.client.ValidateParams(context.Background(), params)
+}
+
+func (a *pluginAdapter) Generate(ctx context.Context, params map[string]interface{}) (*ProviderResult, error) {
+	chunks, err := a.client.Generate(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ProviderResult{Metadata: map[string]interface{}{}}
+	for chunk := range chunks {
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("provider: 插件 %s 生成失败: %s", a.name, chunk.Error)
+		}
+		if len(chunk.ImageChunk) > 0 {
+			result.Images = append(result.Images, chunk.ImageChunk)
+		}
+		for k, v := range chunk.Metadata {
+			result.Metadata[k] = v
+		}
+	}
+	return result, nil
+}
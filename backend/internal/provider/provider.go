@@ -5,11 +5,19 @@ import (
 	"encoding/json"
 	"image-gen-service/internal/config"
 	"image-gen-service/internal/model"
+	"image-gen-service/internal/provider/plugin"
 	"log"
 	"strings"
 	"sync"
+	"time"
 )
 
+// describeTimeout 是连接上插件 sidecar 后，首次 Describe 调用的超时。
+// 和 plugin.Discover 里的 dialTimeout 同理：这个调用发生在 InitProviders
+// 同步路径里，一个接受了连接却不回应 Describe 的 sidecar 不能把整个
+// provider 加载/服务启动卡死。
+const describeTimeout = 5 * time.Second
+
 // ProviderResult 图片生成结果
 type ProviderResult struct {
 	Images   [][]byte               // 图片原始数据列表
@@ -25,9 +33,9 @@ type Provider interface {
 
 // Registry 用于管理不同的 Provider
 var (
-	Registry    = make(map[string]Provider)
-	registryMu  sync.RWMutex
-	initMu      sync.Mutex // 确保 InitProviders 不会被并发调用
+	Registry   = make(map[string]Provider)
+	registryMu sync.RWMutex
+	initMu     sync.Mutex // 确保 InitProviders 不会被并发调用
 )
 
 // Register 注册一个 Provider
@@ -140,6 +148,18 @@ func InitProviders() error {
 		log.Printf("Provider %s 已加载 (BaseURL: %s)", cfg.ProviderName, cfg.APIBase)
 	}
 
+	// 3.5 发现并注册 out-of-process 的插件 provider（gRPC sidecar）。
+	// 新增一个后端不再需要往上面的 switch 里加分支、重新编译这个仓库，
+	// 启动一个 sidecar 后重新调用 InitProviders 即可被发现。
+	for name, p := range discoverPluginProviders() {
+		if _, exists := newRegistry[name]; exists {
+			log.Printf("插件 Provider %s 与内置 Provider 重名，跳过", name)
+			continue
+		}
+		newRegistry[name] = p
+		log.Printf("插件 Provider %s 已加载", name)
+	}
+
 	// 4. 原子替换 Registry
 	registryMu.Lock()
 	Registry = newRegistry
@@ -149,6 +169,31 @@ func InitProviders() error {
 	return nil
 }
 
+// discoverPluginProviders 扫描 providers.d/*.sock 目录和配置文件里列出的
+// endpoints，把每个能连上的插件 sidecar 包装成一个 Provider
+func discoverPluginProviders() map[string]Provider {
+	result := make(map[string]Provider)
+
+	clients, err := plugin.Discover(context.Background(), config.GlobalConfig.PluginSocketDir, config.GlobalConfig.PluginEndpoints)
+	if err != nil {
+		log.Printf("发现插件 Provider 失败: %v", err)
+		return result
+	}
+
+	for _, client := range clients {
+		describeCtx, cancel := context.WithTimeout(context.Background(), describeTimeout)
+		adapter, err := newPluginAdapter(describeCtx, client)
+		cancel()
+		if err != nil {
+			log.Printf("初始化插件 Provider 失败 (%s): %v", client.Target(), err)
+			client.Close()
+			continue
+		}
+		result[adapter.Name()] = adapter
+	}
+	return result
+}
+
 // BuildModelsJSON 构造模型列表 JSON
 func BuildModelsJSON(_ string, modelID, _ string) string {
 	modelID = strings.TrimSpace(modelID)
@@ -168,3 +213,25 @@ func BuildModelsJSON(_ string, modelID, _ string) string {
 	}
 	return string(data)
 }
+
+// BuildModelsJSONFromDescribe 用插件 Describe RPC 返回的能力列表构造模型列表 JSON，
+// 这样管理后台的下拉框能直接展示插件声明的 capabilities，不需要手工维护
+func BuildModelsJSONFromDescribe(modelID string, desc *plugin.DescribeResponse) string {
+	modelID = strings.TrimSpace(modelID)
+	if modelID == "" || desc == nil {
+		return ""
+	}
+	payload := []map[string]interface{}{
+		{
+			"id":           modelID,
+			"name":         desc.Name,
+			"default":      true,
+			"capabilities": desc.Capabilities,
+		},
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"image-gen-service/internal/model"
+	"image-gen-service/internal/provider"
+)
+
+// resultBaseDir 是生成结果图片落盘的默认目录。GenerateWSHandler/
+// TaskStreamHandler 的 complete 事件靠 task.LocalPath 非空才会带上
+// LatestImage（见 websocket.go buildImageInfo），所以结果必须先落盘、
+// 再把路径写回 task。
+const resultBaseDir = "./data/results"
+
+// GenerateParams 是发起一次生成所需的最小参数集合
+type GenerateParams struct {
+	ProviderName string
+	Purpose      provider.ModelPurpose
+	RequestModel string
+	Params       map[string]interface{}
+	Route        provider.RouteRequest
+}
+
+// RunGenerate 驱动一次完整的生成：先把 params 里引用的远程参考图丢进
+// downloader 队列转存到本地，再通过 provider.RouteModel 按能力过滤 +
+// 加权选择 + fallback_ids 重试选出真正产出图片的模型，把结果落盘并将
+// task 更新为 completed/failed，最后把选中结果和进度经 Notifier 回传给
+// 上层。task 的终态必须在通知订阅者之前落到 model.DB 里，否则
+// GenerateWSHandler 的"已完成"快速路径和兜底轮询都看不到这次生成的结果。
+func RunGenerate(ctx context.Context, task *model.Task, params GenerateParams) error {
+	enqueueReferenceImages(task, params.Params)
+
+	var cfg model.ProviderConfig
+	if err := model.DB.Where("provider_name = ?", params.ProviderName).First(&cfg).Error; err != nil {
+		cfg = model.ProviderConfig{}
+	}
+
+	opts := provider.ModelResolveOptions{
+		ProviderName: params.ProviderName,
+		Purpose:      params.Purpose,
+		RequestModel: params.RequestModel,
+		Params:       params.Params,
+		Config:       &cfg,
+	}
+
+	result, resolved, err := provider.RouteModel(ctx, opts, params.Route, params.Params)
+	if err != nil {
+		markTaskFailed(task, err.Error())
+		if Notifier != nil {
+			Notifier.NotifyError(task.TaskID, err.Error())
+		}
+		return fmt.Errorf("worker: 生成失败: %w", err)
+	}
+
+	if err := persistGeneratedImage(task, result); err != nil {
+		markTaskFailed(task, err.Error())
+		if Notifier != nil {
+			Notifier.NotifyError(task.TaskID, err.Error())
+		}
+		return fmt.Errorf("worker: 结果落盘失败: %w", err)
+	}
+
+	if Notifier != nil {
+		Notifier.NotifyModelSelected(task.TaskID, resolved.ID, resolved.Source.Origin)
+		Notifier.NotifyProgress(task.TaskID, len(result.Images), len(result.Images), nil)
+		Notifier.NotifyComplete(task.TaskID, task)
+	}
+	return nil
+}
+
+// persistGeneratedImage 把 RouteModel 产出的第一张图片写入 resultBaseDir，
+// 并把 task 更新为 completed、记录落盘路径和完成时间。只有第一张图片
+// 会落盘，其余图片被丢弃；RunGenerate 的 NotifyProgress 仍以
+// len(result.Images) 作为 completedCount/totalCount。
+func persistGeneratedImage(task *model.Task, result *provider.ProviderResult) error {
+	if len(result.Images) == 0 {
+		return fmt.Errorf("provider 没有返回任何图片")
+	}
+
+	if err := os.MkdirAll(resultBaseDir, 0o755); err != nil {
+		return fmt.Errorf("创建结果目录失败: %w", err)
+	}
+
+	path := filepath.Join(resultBaseDir, task.TaskID+".png")
+	if err := os.WriteFile(path, result.Images[0], 0o644); err != nil {
+		return fmt.Errorf("写入结果文件失败: %w", err)
+	}
+
+	now := time.Now()
+	task.Status = "completed"
+	task.LocalPath = path
+	task.CompletedAt = &now
+
+	return model.DB.Model(&model.Task{}).Where("task_id = ?", task.TaskID).Updates(map[string]interface{}{
+		"status":       task.Status,
+		"local_path":   task.LocalPath,
+		"completed_at": task.CompletedAt,
+	}).Error
+}
+
+// markTaskFailed 把 task 标记为 failed 并记录错误信息，供
+// GenerateWSHandler/TaskStreamHandler 的已终态快速路径和兜底轮询识别。
+func markTaskFailed(task *model.Task, errMsg string) {
+	task.Status = "failed"
+	task.ErrorMessage = errMsg
+	model.DB.Model(&model.Task{}).Where("task_id = ?", task.TaskID).Updates(map[string]interface{}{
+		"status":        task.Status,
+		"error_message": task.ErrorMessage,
+	})
+}
+
+// enqueueReferenceImages 把 params["reference_images"] 里引用的远程地址
+// 投递给 downloader 异步转存到本地，失败只记日志、不影响生成本身——
+// 参考图拉取和生成是两条独立的传输/推理流水线。
+func enqueueReferenceImages(task *model.Task, params map[string]interface{}) {
+	if Downloads == nil || params == nil {
+		return
+	}
+
+	raw, ok := params["reference_images"]
+	if !ok {
+		return
+	}
+
+	sources, ok := raw.([]interface{})
+	if !ok {
+		return
+	}
+
+	for _, item := range sources {
+		source, ok := item.(string)
+		if !ok || source == "" {
+			continue
+		}
+		if err := Downloads.Enqueue(source, "local", task.TaskID, task.UserID); err != nil {
+			log.Printf("[Worker] 投递参考图下载失败 task=%s source=%s: %v", task.TaskID, source, err)
+		}
+	}
+}
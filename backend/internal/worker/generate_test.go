@@ -0,0 +1,22 @@
+package worker
+
+import (
+	"testing"
+
+	"image-gen-service/internal/model"
+	"image-gen-service/internal/provider"
+)
+
+// TestPersistGeneratedImageRequiresAtLeastOneImage 覆盖落盘前的校验：
+// provider 没有返回任何图片时应该直接报错，而不是把任务标记成 completed
+// 却没有任何 LocalPath/ImageURL 可用。
+func TestPersistGeneratedImageRequiresAtLeastOneImage(t *testing.T) {
+	task := &model.Task{TaskID: "no-image-task"}
+
+	if err := persistGeneratedImage(task, &provider.ProviderResult{}); err == nil {
+		t.Fatal("没有图片时 persistGeneratedImage 应该返回错误")
+	}
+	if task.Status == "completed" {
+		t.Fatal("落盘失败时不应该把任务标记为 completed")
+	}
+}
@@ -0,0 +1,30 @@
+// Package worker 驱动一次生成任务的执行：选模型、调用 Provider、
+// 把进度/结果回传给上层（WebSocket/SSE）。
+package worker
+
+import "image-gen-service/internal/model"
+
+// TaskNotifier 是 worker 向上层通知任务状态变化的接口，具体的推送实现
+// （WebSocket Hub、SSE 等）由 internal/api 提供，在启动时赋给 Notifier
+type TaskNotifier interface {
+	NotifyComplete(taskID string, task *model.Task)
+	NotifyError(taskID string, errMsg string)
+	NotifyProgress(taskID string, completedCount, totalCount int, image interface{})
+	// NotifyModelSelected 通知路由/加权选择最终落在了哪个模型上，
+	// 这样客户端能看到"到底是哪个模型产出的图片"
+	NotifyModelSelected(taskID, modelID, source string)
+}
+
+// Notifier 在服务启动时由 api.InitWSNotifier 注入
+var Notifier TaskNotifier
+
+// TaskDownloader 是 worker 用来把生成参数里引用的远程参考图转存到本地/对象存储
+// 的最小接口，具体实现（worker 池、Monitor、Backend）由 internal/downloader 提供，
+// 在 downloader.Start 时注入。worker 不直接依赖 internal/downloader，因为
+// downloader 反过来依赖 worker.Notifier 推送传输进度，直接互相 import 会成环。
+type TaskDownloader interface {
+	Enqueue(source, backendName, taskID, userID string) error
+}
+
+// Downloads 在 downloader.Start 时由 internal/downloader 注入
+var Downloads TaskDownloader